@@ -19,6 +19,7 @@ import (
 	"math"
 	"math/rand"
 	"sort"
+	"sync"
 )
 
 // Pair represents an edge in a directed graph
@@ -29,6 +30,14 @@ type Pair struct {
 	Distance float64
 }
 
+// SamplePair represents an undirected edge between two samples. It is
+// canonicalized so that Idx1 <= Idx2 and, unlike Pair, is added to the
+// graph once; AddSamplePair expands it into both directions internally.
+type SamplePair struct {
+	Idx1, Idx2 uint64
+	Distance   float64
+}
+
 type Edges []Pair
 
 func (e Edges) Len() int { return len(e) }
@@ -45,6 +54,33 @@ type ChineseWhispers struct {
 	numIterations uint64
 	edges         Edges
 	labels        []uint64
+	rng           *rand.Rand
+	convergence   *convergenceConfig
+	// pendingPairs holds canonical SamplePairs added via AddSamplePair that
+	// haven't yet been mirrored into edges. Keeping them separate from
+	// edges means ensureOrdered only ever mirrors each pair once, even
+	// across multiple incremental AddSamplePair/Run cycles.
+	pendingPairs Edges
+}
+
+// convergenceConfig holds the early-stopping settings applied by
+// RunWithStats; see SetConvergence.
+type convergenceConfig struct {
+	minStableSweeps uint64
+	maxSweeps       uint64
+	tolerance       float64
+}
+
+// RunStats reports what happened over the sweeps of a RunWithStats call.
+type RunStats struct {
+	// SweepsExecuted is how many sweeps actually ran.
+	SweepsExecuted uint64
+	// FlipsPerSweep is the number of label changes observed in each sweep,
+	// in order.
+	FlipsPerSweep []int
+	// Converged reports whether the run stopped because the convergence
+	// threshold was reached, as opposed to running out of sweeps.
+	Converged bool
 }
 
 // NewChineseWhispers gives a new ChineseWhispers instance
@@ -54,31 +90,102 @@ func NewChineseWhispers(numIterations uint64) *ChineseWhispers {
 	}
 }
 
-// AddEdge adds graph edges
+// NewChineseWhispersWithRand gives a new ChineseWhispers instance that draws
+// all randomness from rng instead of the math/rand package-level source,
+// making Run's output reproducible across processes for a given seed.
+func NewChineseWhispersWithRand(numIterations uint64, rng *rand.Rand) *ChineseWhispers {
+	return &ChineseWhispers{
+		numIterations: numIterations,
+		rng:           rng,
+	}
+}
+
+// SetRand sets the random source used by Run. Passing nil reverts to the
+// math/rand package-level source.
+func (c *ChineseWhispers) SetRand(rng *rand.Rand) {
+	c.rng = rng
+}
+
+// randInt63 returns a non-negative pseudo-random int64, drawing from the
+// injected rng if one was provided, falling back to the package-level
+// math/rand source otherwise.
+func (c *ChineseWhispers) randInt63() int64 {
+	if c.rng != nil {
+		return c.rng.Int63()
+	}
+	return rand.Int63()
+}
+
+// randIntn returns a pseudo-random number in [0, n), drawing from the
+// injected rng if one was provided, falling back to the package-level
+// math/rand source otherwise.
+func (c *ChineseWhispers) randIntn(n int) int {
+	if c.rng != nil {
+		return c.rng.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
+// SetConvergence enables early stopping for RunWithStats. A sweep is
+// considered stable once its label-flip count drops to or below tolerance
+// (an absolute count when tolerance >= 1, otherwise a fraction of the node
+// count); once minStableSweeps consecutive sweeps are stable, the run
+// stops early. maxSweeps bounds the total number of sweeps regardless of
+// convergence. Run is unaffected and always executes its fixed
+// numIterations schedule.
+func (c *ChineseWhispers) SetConvergence(minStableSweeps, maxSweeps uint64, tolerance float64) {
+	c.convergence = &convergenceConfig{
+		minStableSweeps: minStableSweeps,
+		maxSweeps:       maxSweeps,
+		tolerance:       tolerance,
+	}
+}
+
+// AddEdge adds a pre-oriented, directed edge. Use this when the caller
+// already supplies both directions of an edge (or genuinely wants a
+// directed graph); for undirected input prefer AddSamplePair.
 func (c *ChineseWhispers) AddEdge(pair Pair) {
 	c.edges = append(c.edges, pair)
 }
 
+// AddSamplePair adds an undirected edge between two samples. The pair is
+// canonicalized so Idx1 <= Idx2; callers should use this instead of AddEdge
+// unless they're deliberately building a directed graph. It's expanded into
+// both directions by ensureOrdered, once, the next time the graph is used.
+func (c *ChineseWhispers) AddSamplePair(pair SamplePair) {
+	if pair.Idx1 > pair.Idx2 {
+		pair.Idx1, pair.Idx2 = pair.Idx2, pair.Idx1
+	}
+	c.pendingPairs = append(c.pendingPairs, Pair{
+		Idx1:     pair.Idx1,
+		Idx2:     pair.Idx2,
+		Distance: pair.Distance,
+	})
+}
+
+// ensureOrdered mirrors any pending SamplePairs into both directions and
+// sorts edges. Pairs are moved out of pendingPairs as they're mirrored, so
+// repeated calls (e.g. across incremental AddSamplePair/Run cycles) never
+// re-mirror an edge that's already in edges.
 func (c *ChineseWhispers) ensureOrdered() {
-	if sort.IsSorted(c.edges) {
+	if len(c.pendingPairs) > 0 {
+		for _, p := range c.pendingPairs {
+			c.edges = append(c.edges, p)
+			if p.Idx1 != p.Idx2 {
+				c.edges = append(c.edges, Pair{
+					Idx1:     p.Idx2,
+					Idx2:     p.Idx1,
+					Distance: p.Distance,
+				})
+			}
+		}
+		c.pendingPairs = c.pendingPairs[:0]
+		sort.Sort(c.edges)
 		return
 	}
-	ordered := make(Edges, len(c.edges)*2)
-	for i := 0; i < len(c.edges); i++ {
-		ordered = append(ordered, Pair{
-			Idx1:     c.edges[i].Idx1,
-			Idx2:     c.edges[i].Idx2,
-			Distance: c.edges[i].Distance,
-		})
-		if c.edges[i].Idx1 != c.edges[i].Idx2 {
-			ordered = append(ordered, Pair{
-				Idx1:     c.edges[i].Idx2,
-				Idx2:     c.edges[i].Idx1,
-				Distance: c.edges[i].Distance,
-			})
-		}
+	if !sort.IsSorted(c.edges) {
+		sort.Sort(c.edges)
 	}
-	sort.Sort(ordered)
 }
 
 func (c *ChineseWhispers) findNeighbourRanges(neighbours *[][2]uint64) {
@@ -115,23 +222,21 @@ func (c *ChineseWhispers) findNeighbourRanges(neighbours *[][2]uint64) {
 	}
 }
 
-// Run runs the algorithm returning number of labels
-func (c *ChineseWhispers) Run() int {
-	c.ensureOrdered()
-	c.labels = []uint64{}
-	if c.edges.Len() == 0 {
-		return 0
-	}
-	var neighbours [][2]uint64
-	c.findNeighbourRanges(&neighbours)
-	// Initialize the labels, each node gets a different label.
+// initLabels gives every node its own, distinct label.
+func (c *ChineseWhispers) initLabels(neighbours [][2]uint64) {
 	c.labels = make([]uint64, len(neighbours))
 	for i := 0; i < len(c.labels); i++ {
 		c.labels[i] = uint64(i)
 	}
-	for i := 0; i < len(neighbours)*int(c.numIterations); i++ {
+}
+
+// sweep performs len(neighbours) random node updates and returns how many
+// of them changed a label.
+func (c *ChineseWhispers) sweep(neighbours [][2]uint64) int {
+	flips := 0
+	for i := 0; i < len(neighbours); i++ {
 		// Pick a random node.
-		idx := rand.Int63() % int64(len(neighbours))
+		idx := c.randInt63() % int64(len(neighbours))
 		// Count how many times each label happens amongst our neighbors.
 		labelsToCounts := make(map[uint64]float64)
 		end := neighbours[idx][1]
@@ -140,15 +245,36 @@ func (c *ChineseWhispers) Run() int {
 		}
 		// find the most common label
 		bestScore := math.Inf(-1)
-		bestLabel := c.labels[idx]
+		var ties []uint64
 		for k, v := range labelsToCounts {
 			if v > bestScore {
 				bestScore = v
-				bestLabel = k
+				ties = append(ties[:0], k)
+			} else if v == bestScore {
+				ties = append(ties, k)
 			}
 		}
+		bestLabel := c.labels[idx]
+		if len(ties) == 1 {
+			bestLabel = ties[0]
+		} else if len(ties) > 1 {
+			// labelsToCounts is a map, so the order ties was built in is
+			// randomized per-run independently of c.rng; sort before
+			// indexing so the pick is reproducible for a given seed.
+			sort.Slice(ties, func(i, j int) bool { return ties[i] < ties[j] })
+			bestLabel = ties[c.randIntn(len(ties))]
+		}
+		if bestLabel != c.labels[idx] {
+			flips++
+		}
 		c.labels[idx] = bestLabel
 	}
+	return flips
+}
+
+// remapLabels renumbers c.labels into a contiguous range starting at 0 and
+// returns the number of distinct labels.
+func (c *ChineseWhispers) remapLabels() int {
 	// Remap the labels into a contiguous range.  First we find the
 	// mapping.
 	labelRemap := make(map[uint64]uint64)
@@ -163,6 +289,259 @@ func (c *ChineseWhispers) Run() int {
 	return len(labelRemap)
 }
 
+// Run runs the algorithm for its fixed numIterations sweeps, returning the
+// number of labels. Use RunWithStats for convergence-based early stopping.
+func (c *ChineseWhispers) Run() int {
+	c.ensureOrdered()
+	c.labels = []uint64{}
+	if c.edges.Len() == 0 {
+		return 0
+	}
+	var neighbours [][2]uint64
+	c.findNeighbourRanges(&neighbours)
+	c.initLabels(neighbours)
+	for i := uint64(0); i < c.numIterations; i++ {
+		c.sweep(neighbours)
+	}
+	return c.remapLabels()
+}
+
+// RunWithStats runs the algorithm like Run, but honors any convergence
+// settings from SetConvergence and reports per-sweep statistics. With no
+// convergence configured, it runs the same fixed numIterations schedule as
+// Run and Converged is left false.
+func (c *ChineseWhispers) RunWithStats() (int, RunStats) {
+	c.ensureOrdered()
+	c.labels = []uint64{}
+	if c.edges.Len() == 0 {
+		return 0, RunStats{}
+	}
+	var neighbours [][2]uint64
+	c.findNeighbourRanges(&neighbours)
+	c.initLabels(neighbours)
+
+	maxSweeps := c.numIterations
+	var minStableSweeps uint64
+	var tolerance float64
+	if c.convergence != nil {
+		maxSweeps = c.convergence.maxSweeps
+		minStableSweeps = c.convergence.minStableSweeps
+		tolerance = c.convergence.tolerance
+	}
+	threshold := tolerance
+	if tolerance < 1 {
+		threshold = tolerance * float64(len(neighbours))
+	}
+
+	var stats RunStats
+	var stableStreak uint64
+	for stats.SweepsExecuted < maxSweeps {
+		flips := c.sweep(neighbours)
+		stats.SweepsExecuted++
+		stats.FlipsPerSweep = append(stats.FlipsPerSweep, flips)
+		if c.convergence == nil {
+			continue
+		}
+		if float64(flips) <= threshold {
+			stableStreak++
+		} else {
+			stableStreak = 0
+		}
+		if stableStreak >= minStableSweeps {
+			stats.Converged = true
+			break
+		}
+	}
+	return c.remapLabels(), stats
+}
+
+// shuffle randomizes the order of idxs in place using a Fisher-Yates
+// shuffle driven by c's random source.
+func (c *ChineseWhispers) shuffle(idxs []uint64) {
+	for i := len(idxs) - 1; i > 0; i-- {
+		j := int(c.randInt63() % int64(i+1))
+		idxs[i], idxs[j] = idxs[j], idxs[i]
+	}
+}
+
+// RunParallel runs the algorithm like Run, but partitions nodes across
+// workers goroutines and updates them concurrently within each sweep. Each
+// sweep shuffles the node order and splits it into workers disjoint
+// chunks; every worker computes new labels for its chunk from a read-only
+// snapshot of the labels taken at the start of the sweep, and all updates
+// are applied at a barrier before the next sweep begins (synchronous,
+// label-propagation style). Each worker draws from its own *rand.Rand,
+// seeded deterministically from c's random source, used to break ties
+// between equally-scored labels.
+func (c *ChineseWhispers) RunParallel(workers int) int {
+	c.ensureOrdered()
+	c.labels = []uint64{}
+	if c.edges.Len() == 0 {
+		return 0
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	var neighbours [][2]uint64
+	c.findNeighbourRanges(&neighbours)
+	c.initLabels(neighbours)
+
+	order := make([]uint64, len(neighbours))
+	for i := range order {
+		order[i] = uint64(i)
+	}
+	workerRngs := make([]*rand.Rand, workers)
+	for w := range workerRngs {
+		workerRngs[w] = rand.New(rand.NewSource(c.randInt63()))
+	}
+
+	chunkBounds := func(w int) (int, int) {
+		chunkSize := (len(order) + workers - 1) / workers
+		start := w * chunkSize
+		if start > len(order) {
+			start = len(order)
+		}
+		end := start + chunkSize
+		if end > len(order) {
+			end = len(order)
+		}
+		return start, end
+	}
+
+	for sweep := uint64(0); sweep < c.numIterations; sweep++ {
+		c.shuffle(order)
+		snapshot := make([]uint64, len(c.labels))
+		copy(snapshot, c.labels)
+		updates := make([][]uint64, workers)
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			start, end := chunkBounds(w)
+			if start == end {
+				continue
+			}
+			wg.Add(1)
+			go func(w, start, end int) {
+				defer wg.Done()
+				rng := workerRngs[w]
+				labelsToCounts := make(map[uint64]float64)
+				newLabels := make([]uint64, 0, end-start)
+				var ties []uint64
+				for _, idx := range order[start:end] {
+					for k := range labelsToCounts {
+						delete(labelsToCounts, k)
+					}
+					endRange := neighbours[idx][1]
+					for n := neighbours[idx][0]; n != endRange; n++ {
+						labelsToCounts[snapshot[c.edges[n].Idx2]] += c.edges[n].Distance
+					}
+					bestScore := math.Inf(-1)
+					ties = ties[:0]
+					for k, v := range labelsToCounts {
+						if v > bestScore {
+							bestScore = v
+							ties = append(ties[:0], k)
+						} else if v == bestScore {
+							ties = append(ties, k)
+						}
+					}
+					bestLabel := snapshot[idx]
+					if len(ties) == 1 {
+						bestLabel = ties[0]
+					} else if len(ties) > 1 {
+						// labelsToCounts is a map, so the order ties was
+						// built in is randomized per-run independently of
+						// rng; sort before indexing so the pick is
+						// reproducible for a given seed.
+						sort.Slice(ties, func(i, j int) bool { return ties[i] < ties[j] })
+						bestLabel = ties[rng.Intn(len(ties))]
+					}
+					newLabels = append(newLabels, bestLabel)
+				}
+				updates[w] = newLabels
+			}(w, start, end)
+		}
+		wg.Wait()
+
+		for w := 0; w < workers; w++ {
+			start, end := chunkBounds(w)
+			for i, idx := range order[start:end] {
+				c.labels[idx] = updates[w][i]
+			}
+		}
+	}
+	return c.remapLabels()
+}
+
+// Modularity computes Newman's modularity Q for the current labeling: for
+// a weighted undirected graph with total edge weight m (summed once per
+// canonical, deduplicated edge) and weighted degree k_i for node i,
+// Q = (1/2m) * Σ_ij [w_ij - k_i*k_j/(2m)] * δ(label_i, label_j), the sum
+// running over all pairs of nodes. It is computed as the intra-cluster
+// edge weight (both orientations of every edge) over 2m, minus the sum over
+// clusters of the squared total degree over (2m)^2. Returns 0 if Run hasn't
+// been called yet or the graph carries no weight.
+func (c *ChineseWhispers) Modularity() float64 {
+	if len(c.labels) == 0 {
+		return 0
+	}
+	c.ensureOrdered()
+	degree := make([]float64, len(c.labels))
+	var totalWeight, intraWeight float64
+	for i := 0; i < len(c.edges); i++ {
+		e := c.edges[i]
+		degree[e.Idx1] += e.Distance
+		if e.Idx1 <= e.Idx2 {
+			totalWeight += e.Distance
+		}
+		if c.labels[e.Idx1] == c.labels[e.Idx2] {
+			intraWeight += e.Distance
+		}
+	}
+	if totalWeight == 0 {
+		return 0
+	}
+	twoM := 2 * totalWeight
+	clusterDegree := make([]float64, len(c.labels))
+	for i, k := range degree {
+		clusterDegree[c.labels[i]] += k
+	}
+	var expected float64
+	for _, k := range clusterDegree {
+		expected += k * k
+	}
+	return intraWeight/twoM - expected/(twoM*twoM)
+}
+
+// RunBest runs the algorithm restarts times, each seeded from a distinct
+// draw of c's random source, and keeps the labeling with the highest
+// Modularity as the final state. This combats the randomness inherent to
+// Chinese Whispers, where a single Run can land in a poor partition.
+func (c *ChineseWhispers) RunBest(restarts int) (int, float64) {
+	if restarts < 1 {
+		restarts = 1
+	}
+	origRng := c.rng
+	defer func() { c.rng = origRng }()
+
+	var bestLabels []uint64
+	var bestNumLabels int
+	bestQ := math.Inf(-1)
+	for i := 0; i < restarts; i++ {
+		c.rng = origRng
+		c.rng = rand.New(rand.NewSource(c.randInt63()))
+		numLabels := c.Run()
+		q := c.Modularity()
+		if q > bestQ {
+			bestQ = q
+			bestNumLabels = numLabels
+			bestLabels = append([]uint64(nil), c.labels...)
+		}
+	}
+	c.labels = bestLabels
+	return bestNumLabels, bestQ
+}
+
 // GetLabel returns the label at the index idx
 func (c *ChineseWhispers) GetLabel(idx uint64) uint64 {
 	return c.labels[idx]
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2017 Onchere Bironga
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package gocw
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// buildSyntheticEdges builds a random graph with roughly numEdges edges
+// over numNodes nodes, for benchmarking Run against RunParallel.
+func buildSyntheticEdges(numNodes, numEdges int) Edges {
+	rng := rand.New(rand.NewSource(1))
+	edges := make(Edges, 0, numEdges)
+	for i := 0; i < numEdges; i++ {
+		u := uint64(rng.Intn(numNodes))
+		v := uint64(rng.Intn(numNodes))
+		edges = append(edges, Pair{Idx1: u, Idx2: v, Distance: 1})
+	}
+	return edges
+}
+
+func BenchmarkRunSequential(b *testing.B) {
+	edges := buildSyntheticEdges(20000, 1000000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := NewChineseWhispersWithRand(5, rand.New(rand.NewSource(int64(i))))
+		for _, e := range edges {
+			c.AddEdge(e)
+		}
+		c.Run()
+	}
+}
+
+func BenchmarkRunParallel(b *testing.B) {
+	edges := buildSyntheticEdges(20000, 1000000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := NewChineseWhispersWithRand(5, rand.New(rand.NewSource(int64(i))))
+		for _, e := range edges {
+			c.AddEdge(e)
+		}
+		c.RunParallel(8)
+	}
+}
@@ -0,0 +1,304 @@
+/*
+ * Copyright 2017 Onchere Bironga
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package gocw
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func buildSampleEdges() Edges {
+	return Edges{
+		{Idx1: 0, Idx2: 1, Distance: 1},
+		{Idx1: 1, Idx2: 0, Distance: 1},
+		{Idx1: 1, Idx2: 2, Distance: 1},
+		{Idx1: 2, Idx2: 1, Distance: 1},
+		{Idx1: 2, Idx2: 0, Distance: 1},
+		{Idx1: 0, Idx2: 2, Distance: 1},
+		{Idx1: 3, Idx2: 4, Distance: 1},
+		{Idx1: 4, Idx2: 3, Distance: 1},
+	}
+}
+
+func TestRunWithSeededRandIsDeterministic(t *testing.T) {
+	run := func(seed int64) []uint64 {
+		c := NewChineseWhispersWithRand(10, rand.New(rand.NewSource(seed)))
+		for _, e := range buildSampleEdges() {
+			c.AddEdge(e)
+		}
+		c.Run()
+		return c.GetLabels()
+	}
+
+	first := run(42)
+	second := run(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical labels for identical seeds, got %v and %v", first, second)
+	}
+}
+
+func TestRunIsDeterministicWithTiedNeighbourLabels(t *testing.T) {
+	run := func() []uint64 {
+		c := NewChineseWhispersWithRand(20, rand.New(rand.NewSource(42)))
+		// A 4-clique with uniform weights guarantees label-count ties on
+		// the first sweep, while every neighbour still has a distinct
+		// label: picking among the tied labels must be driven entirely by
+		// c's seeded source, not by Go's randomized map iteration order.
+		nodes := []uint64{0, 1, 2, 3}
+		for _, u := range nodes {
+			for _, v := range nodes {
+				if u != v {
+					c.AddEdge(Pair{Idx1: u, Idx2: v, Distance: 1})
+				}
+			}
+		}
+		c.Run()
+		return c.GetLabels()
+	}
+
+	want := run()
+	for i := 0; i < 20; i++ {
+		if got := run(); !reflect.DeepEqual(want, got) {
+			t.Fatalf("run %d diverged: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSetRandOverridesSource(t *testing.T) {
+	c := NewChineseWhispers(10)
+	for _, e := range buildSampleEdges() {
+		c.AddEdge(e)
+	}
+	c.SetRand(rand.New(rand.NewSource(7)))
+	c.Run()
+	first := c.GetLabels()
+
+	c2 := NewChineseWhispers(10)
+	for _, e := range buildSampleEdges() {
+		c2.AddEdge(e)
+	}
+	c2.SetRand(rand.New(rand.NewSource(7)))
+	c2.Run()
+	second := c2.GetLabels()
+
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical labels when SetRand seeded identically, got %v and %v", first, second)
+	}
+}
+
+func TestEnsureOrderedExpandsAndSortsSamplePairs(t *testing.T) {
+	c := NewChineseWhispers(1)
+	c.AddSamplePair(SamplePair{Idx1: 2, Idx2: 0, Distance: 1})
+	c.AddSamplePair(SamplePair{Idx1: 0, Idx2: 1, Distance: 1})
+	c.ensureOrdered()
+
+	want := Edges{
+		{Idx1: 0, Idx2: 1, Distance: 1},
+		{Idx1: 0, Idx2: 2, Distance: 1},
+		{Idx1: 1, Idx2: 0, Distance: 1},
+		{Idx1: 2, Idx2: 0, Distance: 1},
+	}
+	if !reflect.DeepEqual(c.edges, want) {
+		t.Fatalf("ensureOrdered() = %v, want %v", c.edges, want)
+	}
+}
+
+func TestEnsureOrderedExpandsSamplePairsAddedInIncreasingOrder(t *testing.T) {
+	// Regression test: adding SamplePairs with non-decreasing (Idx1, Idx2)
+	// used to make c.edges already satisfy sort.IsSorted, tripping the fast
+	// path in ensureOrdered and skipping expansion into both directions.
+	c := NewChineseWhispers(1)
+	c.AddSamplePair(SamplePair{Idx1: 0, Idx2: 1, Distance: 1})
+	c.AddSamplePair(SamplePair{Idx1: 1, Idx2: 2, Distance: 1})
+	c.AddSamplePair(SamplePair{Idx1: 2, Idx2: 3, Distance: 1})
+	c.ensureOrdered()
+
+	want := Edges{
+		{Idx1: 0, Idx2: 1, Distance: 1},
+		{Idx1: 1, Idx2: 0, Distance: 1},
+		{Idx1: 1, Idx2: 2, Distance: 1},
+		{Idx1: 2, Idx2: 1, Distance: 1},
+		{Idx1: 2, Idx2: 3, Distance: 1},
+		{Idx1: 3, Idx2: 2, Distance: 1},
+	}
+	if !reflect.DeepEqual(c.edges, want) {
+		t.Fatalf("ensureOrdered() = %v, want %v", c.edges, want)
+	}
+}
+
+func TestIncrementalAddSamplePairAcrossRunsDoesNotDuplicateEdges(t *testing.T) {
+	// Regression test: ensureOrdered used to re-mirror every entry already
+	// in c.edges on each call, so an AddSamplePair/Run cycle following an
+	// earlier one would double-weight the edges added before it.
+	c := NewChineseWhispersWithRand(20, rand.New(rand.NewSource(1)))
+	c.AddSamplePair(SamplePair{Idx1: 0, Idx2: 1, Distance: 1})
+	c.Run()
+	c.AddSamplePair(SamplePair{Idx1: 2, Idx2: 3, Distance: 1})
+	c.Run()
+
+	want := Edges{
+		{Idx1: 0, Idx2: 1, Distance: 1},
+		{Idx1: 1, Idx2: 0, Distance: 1},
+		{Idx1: 2, Idx2: 3, Distance: 1},
+		{Idx1: 3, Idx2: 2, Distance: 1},
+	}
+	if !reflect.DeepEqual(c.edges, want) {
+		t.Fatalf("edges after incremental AddSamplePair/Run cycles = %v, want %v", c.edges, want)
+	}
+
+	labels := c.GetLabels()
+	if labels[0] != labels[1] {
+		t.Fatalf("expected nodes 0 and 1 to share a label, got %v", labels)
+	}
+	if labels[2] != labels[3] {
+		t.Fatalf("expected nodes 2 and 3 to share a label, got %v", labels)
+	}
+	if labels[0] == labels[2] {
+		t.Fatalf("expected the two disjoint components to get different labels, got %v", labels)
+	}
+}
+
+func TestEnsureOrderedSkipsAlreadyOrderedEdges(t *testing.T) {
+	c := NewChineseWhispers(1)
+	c.AddEdge(Pair{Idx1: 0, Idx2: 1, Distance: 1})
+	c.AddEdge(Pair{Idx1: 1, Idx2: 0, Distance: 1})
+	c.ensureOrdered()
+
+	want := Edges{
+		{Idx1: 0, Idx2: 1, Distance: 1},
+		{Idx1: 1, Idx2: 0, Distance: 1},
+	}
+	if !reflect.DeepEqual(c.edges, want) {
+		t.Fatalf("ensureOrdered() = %v, want %v", c.edges, want)
+	}
+}
+
+func TestRunWithStatsConvergesEarly(t *testing.T) {
+	c := NewChineseWhispersWithRand(1000, rand.New(rand.NewSource(1)))
+	for _, e := range buildSampleEdges() {
+		c.AddEdge(e)
+	}
+	c.SetConvergence(2, 1000, 0)
+	_, stats := c.RunWithStats()
+
+	if !stats.Converged {
+		t.Fatalf("expected RunWithStats to converge, got stats %+v", stats)
+	}
+	if stats.SweepsExecuted >= 1000 {
+		t.Fatalf("expected early stop well before maxSweeps, executed %d sweeps", stats.SweepsExecuted)
+	}
+	if len(stats.FlipsPerSweep) != int(stats.SweepsExecuted) {
+		t.Fatalf("len(FlipsPerSweep) = %d, want %d", len(stats.FlipsPerSweep), stats.SweepsExecuted)
+	}
+}
+
+func TestRunWithStatsWithoutConvergenceMatchesNumIterations(t *testing.T) {
+	c := NewChineseWhispers(5)
+	for _, e := range buildSampleEdges() {
+		c.AddEdge(e)
+	}
+	_, stats := c.RunWithStats()
+
+	if stats.Converged {
+		t.Fatalf("expected Converged to be false with no convergence configured")
+	}
+	if stats.SweepsExecuted != 5 {
+		t.Fatalf("SweepsExecuted = %d, want 5", stats.SweepsExecuted)
+	}
+}
+
+func TestRunParallelIsDeterministicForSameSeed(t *testing.T) {
+	run := func() []uint64 {
+		c := NewChineseWhispersWithRand(10, rand.New(rand.NewSource(99)))
+		for _, e := range buildSampleEdges() {
+			c.AddEdge(e)
+		}
+		c.RunParallel(4)
+		return c.GetLabels()
+	}
+
+	first := run()
+	second := run()
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical labels for identical seeds, got %v and %v", first, second)
+	}
+}
+
+func TestModularityOfPerfectPartitionIsPositive(t *testing.T) {
+	c := NewChineseWhispersWithRand(50, rand.New(rand.NewSource(3)))
+	for _, e := range buildSampleEdges() {
+		c.AddEdge(e)
+	}
+	c.Run()
+
+	q := c.Modularity()
+	if q <= 0 {
+		t.Fatalf("expected positive modularity for a clustering of two disjoint triangles/edges, got %v", q)
+	}
+}
+
+func TestModularityBeforeRunIsZero(t *testing.T) {
+	c := NewChineseWhispers(10)
+	for _, e := range buildSampleEdges() {
+		c.AddEdge(e)
+	}
+	if q := c.Modularity(); q != 0 {
+		t.Fatalf("expected 0 modularity before Run, got %v", q)
+	}
+}
+
+func TestRunBestPicksHighestModularity(t *testing.T) {
+	c := NewChineseWhispersWithRand(5, rand.New(rand.NewSource(11)))
+	for _, e := range buildSampleEdges() {
+		c.AddEdge(e)
+	}
+	_, q := c.RunBest(5)
+
+	c2 := NewChineseWhispersWithRand(5, rand.New(rand.NewSource(11)))
+	for _, e := range buildSampleEdges() {
+		c2.AddEdge(e)
+	}
+	c2.Run()
+	baselineQ := c2.Modularity()
+
+	if q < baselineQ {
+		t.Fatalf("RunBest modularity %v should be at least as good as a single run's %v", q, baselineQ)
+	}
+	if q != c.Modularity() {
+		t.Fatalf("RunBest returned q=%v but the restored labeling has modularity %v", q, c.Modularity())
+	}
+}
+
+func TestEnsureOrderedWithMixedInput(t *testing.T) {
+	c := NewChineseWhispers(1)
+	// AddEdge is pre-oriented: the caller supplies both directions.
+	c.AddEdge(Pair{Idx1: 1, Idx2: 0, Distance: 1})
+	c.AddEdge(Pair{Idx1: 0, Idx2: 1, Distance: 1})
+	// AddSamplePair is undirected: ensureOrdered mirrors it for us.
+	c.AddSamplePair(SamplePair{Idx1: 0, Idx2: 2, Distance: 1})
+	c.ensureOrdered()
+
+	want := Edges{
+		{Idx1: 0, Idx2: 1, Distance: 1},
+		{Idx1: 0, Idx2: 2, Distance: 1},
+		{Idx1: 1, Idx2: 0, Distance: 1},
+		{Idx1: 2, Idx2: 0, Distance: 1},
+	}
+	if !reflect.DeepEqual(c.edges, want) {
+		t.Fatalf("ensureOrdered() = %v, want %v", c.edges, want)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2017 Onchere Bironga
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gocwgonum adapts gonum.org/v1/gonum/graph graphs to gocw, so that
+// callers already modeling their data with gonum's graph types don't have to
+// flatten nodes and edges into gocw.Pair values by hand.
+package gocwgonum
+
+import (
+	"github.com/onchere/gocw"
+	"gonum.org/v1/gonum/graph"
+)
+
+// FromGraph builds a *gocw.ChineseWhispers from g, running iterations sweeps
+// over each node when Run is called. g can be unweighted (implementing only
+// graph.Graph), in which case every edge gets a Distance of 1.0, or it can
+// additionally implement graph.Weighted to supply real edge weights. Nodes
+// are assigned dense uint64 indices in the order returned by g.Nodes();
+// prefer Cluster if you want the resulting labels keyed by the original
+// gonum node IDs.
+func FromGraph(g graph.Graph, iterations uint64) *gocw.ChineseWhispers {
+	c, _ := fromGraph(g, iterations)
+	return c
+}
+
+// Cluster runs Chinese Whispers clustering over g for the given number of
+// iterations and returns a label for every node, keyed by the node's
+// original gonum ID. Nodes with no edges are each assigned their own
+// singleton label: Run leaves an edgeless node's label unchanged, and nodes
+// indexed past the last node with an edge get a fresh label here.
+func Cluster(g graph.Graph, iterations uint64) map[int64]uint64 {
+	c, nodeIdx := fromGraph(g, iterations)
+	c.Run()
+	labels := c.GetLabels()
+	result := make(map[int64]uint64, len(nodeIdx))
+	nextLabel := uint64(len(labels))
+	for id, idx := range nodeIdx {
+		if idx < uint64(len(labels)) {
+			result[id] = labels[idx]
+			continue
+		}
+		// idx falls past the end of labels for nodes Run never assigned a
+		// range to (e.g. isolated nodes with no outgoing edges).
+		result[id] = nextLabel
+		nextLabel++
+	}
+	return result
+}
+
+func fromGraph(g graph.Graph, iterations uint64) (*gocw.ChineseWhispers, map[int64]uint64) {
+	weighted, _ := g.(graph.Weighted)
+	nodeIdx := indexNodes(g)
+	c := gocw.NewChineseWhispers(iterations)
+	nodes := g.Nodes()
+	for nodes.Next() {
+		u := nodes.Node().ID()
+		to := g.From(u)
+		for to.Next() {
+			v := to.Node().ID()
+			weight := 1.0
+			if weighted != nil {
+				if w, ok := weighted.Weight(u, v); ok {
+					weight = w
+				}
+			}
+			c.AddEdge(gocw.Pair{
+				Idx1:     nodeIdx[u],
+				Idx2:     nodeIdx[v],
+				Distance: weight,
+			})
+		}
+	}
+	return c, nodeIdx
+}
+
+// indexNodes assigns each node in g a dense uint64 index in iteration order,
+// which is what gocw.Pair expects in place of gonum's sparse int64 IDs.
+func indexNodes(g graph.Graph) map[int64]uint64 {
+	nodeIdx := make(map[int64]uint64)
+	nodes := g.Nodes()
+	for nodes.Next() {
+		id := nodes.Node().ID()
+		if _, ok := nodeIdx[id]; !ok {
+			nodeIdx[id] = uint64(len(nodeIdx))
+		}
+	}
+	return nodeIdx
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2017 Onchere Bironga
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package gocwgonum
+
+import (
+	"testing"
+
+	"gonum.org/v1/gonum/graph/simple"
+)
+
+func TestClusterHandlesIsolatedNodes(t *testing.T) {
+	g := simple.NewWeightedUndirectedGraph(0, 0)
+	g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(0), simple.Node(1), 1))
+	g.AddNode(simple.Node(2)) // isolated: no edges
+
+	labels := Cluster(g, 10)
+	if len(labels) != 3 {
+		t.Fatalf("len(labels) = %d, want 3", len(labels))
+	}
+	if labels[0] != labels[1] {
+		t.Fatalf("expected nodes 0 and 1 to share a label, got %v and %v", labels[0], labels[1])
+	}
+	if labels[2] == labels[0] {
+		t.Fatalf("expected isolated node 2 to get its own label, got %v", labels[2])
+	}
+}
+
+func TestClusterAcceptsUnweightedGraph(t *testing.T) {
+	g := simple.NewUndirectedGraph()
+	g.SetEdge(g.NewEdge(simple.Node(0), simple.Node(1)))
+	g.SetEdge(g.NewEdge(simple.Node(1), simple.Node(2)))
+
+	labels := Cluster(g, 10)
+	if len(labels) != 3 {
+		t.Fatalf("len(labels) = %d, want 3", len(labels))
+	}
+}
+
+func TestFromGraphLabelsEveryNodeOfUnweightedGraph(t *testing.T) {
+	g := simple.NewUndirectedGraph()
+	g.SetEdge(g.NewEdge(simple.Node(0), simple.Node(1)))
+
+	c := FromGraph(g, 1)
+	c.Run()
+	if len(c.GetLabels()) != 2 {
+		t.Fatalf("len(labels) = %d, want 2", len(c.GetLabels()))
+	}
+}